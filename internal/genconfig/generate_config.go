@@ -0,0 +1,1005 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: BUSL-1.1
+
+// Package genconfig renders cty values read from a provider (as state,
+// or as the result of a list resource query) back into HCL resource
+// configuration. It backs both `terraform add` (building a blank
+// skeleton for a resource that has no configuration yet) and
+// `terraform plan -generate-config-out` (building configuration that
+// matches an imported resource's real state).
+package genconfig
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+	"unicode"
+
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/hashicorp/hcl/v2/hclwrite"
+	"github.com/zclconf/go-cty/cty"
+
+	"github.com/hashicorp/terraform/internal/addrs"
+	"github.com/hashicorp/terraform/internal/configs/configschema"
+	"github.com/hashicorp/terraform/internal/lang/marks"
+	"github.com/hashicorp/terraform/internal/tfdiags"
+)
+
+// SensitiveValueStyle selects how FormatPolicy renders attributes marked
+// sensitive.
+type SensitiveValueStyle int
+
+const (
+	// SensitiveValuesElided renders a sensitive attribute as `null #
+	// sensitive`. This is the default.
+	SensitiveValuesElided SensitiveValueStyle = iota
+
+	// SensitiveValuesPlaceholder renders a sensitive attribute as a
+	// `sensitive("...")` placeholder, so the generated config is valid
+	// HCL that a human can find-and-replace without also having to
+	// clear an explanatory comment.
+	SensitiveValuesPlaceholder
+)
+
+// FormatPolicy controls optional aspects of how GenerateResourceContents
+// and its siblings render HCL. The zero value reproduces the package's
+// original, fixed behavior, so callers that don't care can pass
+// FormatPolicy{}.
+type FormatPolicy struct {
+	// HeredocMinLines is the minimum number of lines a multi-line
+	// string value must have before it is rendered as a `<<-EOT`
+	// heredoc instead of an escaped quoted string. Zero (the default)
+	// never uses heredocs.
+	HeredocMinLines int
+
+	// JSONEncodeMinChars is the minimum length a string must have,
+	// once confirmed to be parseable JSON, before it is rewritten as
+	// jsonencode(...) rather than left as a quoted string. Zero (the
+	// default) rewrites any parseable JSON object or array, regardless
+	// of size.
+	JSONEncodeMinChars int
+
+	// OmitHints suppresses the trailing "# OPTIONAL ..." / "#
+	// REQUIRED ..." comments that are otherwise added when generating
+	// a blank skeleton (stateVal == cty.NilVal).
+	OmitHints bool
+
+	// SensitiveValues selects how sensitive attributes are rendered.
+	SensitiveValues SensitiveValueStyle
+}
+
+// GenerateResourceContents generates HCL configuration for the given
+// resource, using the schema to guide the generation and stateVal to
+// populate the values in the configuration.
+//
+// If stateVal is cty.NilVal there is no state to draw from at all, so
+// the generated configuration is a blank skeleton: every attribute is
+// set to null, and optional/required attributes and blocks are called
+// out with a trailing comment so a human can fill them in.
+// policy.OmitHints suppresses those comments even in that case; the
+// rest of policy controls heredocs, jsonencode(...) rewriting, and how
+// sensitive values are elided (by default as `null # sensitive`,
+// whether or not there is state).
+func GenerateResourceContents(addr addrs.AbsResourceInstance, schema *configschema.Block, pc addrs.LocalProviderConfig, stateVal cty.Value, policy FormatPolicy) (*bytes.Buffer, tfdiags.Diagnostics) {
+	var diags tfdiags.Diagnostics
+
+	showHints := stateVal == cty.NilVal && !policy.OmitHints
+	buf := renderResourceBlock(addr, schema, pc, stateVal, showHints, false, policy)
+	return buf, diags
+}
+
+// renderResourceBlock writes the `resource "type" "name" { ... }` block
+// for addr. alwaysShowProvider bypasses the usual rule of omitting the
+// provider meta-argument when it matches the resource type's default
+// provider; -generate-config-out output always wants it spelled out
+// explicitly, regardless of what's implied.
+func renderResourceBlock(addr addrs.AbsResourceInstance, schema *configschema.Block, pc addrs.LocalProviderConfig, stateVal cty.Value, showHints bool, alwaysShowProvider bool, policy FormatPolicy) *bytes.Buffer {
+	var buf bytes.Buffer
+
+	resource := addr.Resource.Resource
+	buf.WriteString(fmt.Sprintf("resource %q %q {\n", resource.Type, resource.Name))
+	writeResourceProvider(&buf, resource, pc, alwaysShowProvider)
+	writeBlockContents(&buf, "  ", schema, stateVal, showHints, policy)
+	buf.WriteString("}")
+
+	formatted := hclwrite.Format(buf.Bytes())
+	buf.Reset()
+	buf.Write(formatted)
+	return &buf
+}
+
+// ResourceImportInstance pairs one instance of a resource (identified by
+// its for_each/count key) with the state and import identifier a
+// provider returned for it. ImportID is set when the provider returned a
+// legacy string import ID; Identity is set when it returned a resource
+// identity instead. Exactly one of the two is expected to be populated;
+// if neither is, writeImportBlock reports a diagnostic instead of
+// emitting an invalid id = "" import block.
+type ResourceImportInstance struct {
+	Key      addrs.InstanceKey
+	StateVal cty.Value
+	ImportID string
+	Identity cty.Value
+}
+
+// GenerateResourceAndImportContents is the bulk-import sibling of
+// GenerateResourceContents: given every instance a provider matched for
+// a single resource (as produced by, for example, a `for_each`/`count`
+// import query), it renders one resource/import block pair per
+// instance, in deterministic key order, so the result can be written
+// directly into a `-generate-config-out` file.
+func GenerateResourceAndImportContents(addr addrs.AbsResource, schema *configschema.Block, idSchema *configschema.Object, pc addrs.LocalProviderConfig, instances []ResourceImportInstance, policy FormatPolicy) (*ListResourceContents, tfdiags.Diagnostics) {
+	var diags tfdiags.Diagnostics
+
+	sorted := make([]ResourceImportInstance, len(instances))
+	copy(sorted, instances)
+	sort.Slice(sorted, func(i, j int) bool {
+		return instanceKeyLess(sorted[i].Key, sorted[j].Key)
+	})
+
+	results := make([]GeneratedResource, 0, len(sorted))
+	for _, inst := range sorted {
+		instAddr := addr.Instance(inst.Key)
+
+		content := renderResourceBlock(instAddr, schema, pc, inst.StateVal, false, true, policy)
+		imp, impDiags := writeImportBlock(instAddr, pc, idSchema, inst.ImportID, inst.Identity)
+		diags = diags.Append(impDiags)
+		if impDiags.HasErrors() {
+			continue
+		}
+
+		results = append(results, GeneratedResource{
+			Addr:    instAddr,
+			content: content,
+			imp:     imp,
+		})
+	}
+
+	return &ListResourceContents{Results: results}, diags
+}
+
+// instanceKeyLess orders instance keys the way a user reading the
+// generated file would expect: numerically for count-based
+// (addrs.IntKey) keys, so a bulk import of more than nine instances
+// doesn't come out as 0, 1, 10, 11, ..., 2, 20, ...; lexicographically
+// for everything else (addrs.StringKey, or a mix of key types).
+func instanceKeyLess(a, b addrs.InstanceKey) bool {
+	if ai, ok := a.(addrs.IntKey); ok {
+		if bi, ok := b.(addrs.IntKey); ok {
+			return ai < bi
+		}
+	}
+	return a.String() < b.String()
+}
+
+// ListResourceContents is the result of generating HCL for every
+// instance produced by a list resource query: one resource/import block
+// pair per matched object, or a single for_each pair covering all of
+// them when GenerateListResourceContents collapses its output.
+type ListResourceContents struct {
+	Results []GeneratedResource
+}
+
+// String concatenates every generated resource/import pair, in the
+// order they were generated, separated by a blank line.
+func (l *ListResourceContents) String() string {
+	var buf bytes.Buffer
+	for i, r := range l.Results {
+		if i > 0 {
+			buf.WriteString("\n")
+		}
+		buf.WriteString(r.String())
+	}
+	return buf.String()
+}
+
+// GeneratedResource is one resource/import pair of the output of
+// GenerateListResourceContents or GenerateResourceAndImportContents: a
+// resource block and the import block needed to adopt it (for one
+// instance, or for every instance in collapsed for_each form).
+type GeneratedResource struct {
+	Addr    addrs.AbsResourceInstance
+	content *bytes.Buffer
+	imp     *bytes.Buffer
+}
+
+// String returns the resource block followed by its import block.
+func (g GeneratedResource) String() string {
+	var buf bytes.Buffer
+	buf.Write(g.content.Bytes())
+	buf.WriteString("\n")
+	buf.Write(g.imp.Bytes())
+	return buf.String()
+}
+
+// NamingStrategyKind selects how GenerateListResourceContents derives
+// each instance's resource name from its result object.
+type NamingStrategyKind int
+
+const (
+	// NamingByIndex names each instance by appending its position in
+	// the query result to the list resource's own name, e.g.
+	// "example_0", "example_1", .... This is the default, but the
+	// names it produces are unstable across result re-orderings.
+	NamingByIndex NamingStrategyKind = iota
+
+	// NamingByTemplate names each instance by executing a
+	// text/template against its result object, which is exposed to
+	// the template as ".state" and ".identity", e.g.
+	// "{{ .identity.id }}".
+	NamingByTemplate
+
+	// NamingByAttribute names each instance from the value of a
+	// designated top-level state attribute.
+	NamingByAttribute
+)
+
+// NamingStrategy selects and configures how GenerateListResourceContents
+// names the resource it generates for each result instance. Whatever
+// name it derives is slugified into a valid HCL identifier and
+// disambiguated against any earlier collision. The zero value is
+// NamingByIndex, reproducing the package's original "example_0",
+// "example_1", ... behavior.
+type NamingStrategy struct {
+	Kind NamingStrategyKind
+
+	// Template is the text/template body evaluated when Kind is
+	// NamingByTemplate.
+	Template string
+
+	// Attribute is the name of the state attribute read when Kind is
+	// NamingByAttribute.
+	Attribute string
+}
+
+// listResourceInstance pairs one object from a list resource query's
+// result with the identity the provider reported for it.
+type listResourceInstance struct {
+	stateVal    cty.Value
+	identityVal cty.Value
+}
+
+// GenerateListResourceContents renders the resource and import blocks
+// needed to adopt every object returned by a list resource query. value
+// is expected to be a tuple (or list) of objects, each with a "state"
+// attribute (the would-be resource state) and an "identity" attribute
+// (the resource identity the provider reported for it). naming selects
+// how each instance's resource name (or, in collapsed mode, its
+// for_each key) is derived from its result object; its zero value names
+// instances "example_0", "example_1", ... after the list resource's own
+// name.
+//
+// If collapse is true and every instance shares a schema-compatible
+// state shape and carries an identity, a single resource block using
+// `for_each` is emitted (plus a single `for_each` import block), rather
+// than one resource/import pair per instance. collapse has no effect
+// when the schema has nested blocks, since those can't be referenced
+// through `each.value` without `dynamic` blocks; in that case, and
+// whenever the instances are heterogeneous, GenerateListResourceContents
+// falls back to its normal unrolled output.
+func GenerateListResourceContents(addr addrs.AbsResourceInstance, schema *configschema.Block, idSchema *configschema.Object, pc addrs.LocalProviderConfig, value cty.Value, policy FormatPolicy, naming NamingStrategy, collapse bool) (*ListResourceContents, tfdiags.Diagnostics) {
+	var diags tfdiags.Diagnostics
+
+	if value == cty.NilVal || value.IsNull() || !value.IsKnown() {
+		return &ListResourceContents{}, diags
+	}
+
+	instances := make([]listResourceInstance, 0, value.LengthInt())
+	for it := value.ElementIterator(); it.Next(); {
+		_, elem := it.Element()
+
+		stateVal := elem.GetAttr("state")
+		identityVal := cty.NilVal
+		if elem.Type().HasAttribute("identity") {
+			identityVal = elem.GetAttr("identity")
+		}
+		instances = append(instances, listResourceInstance{stateVal: stateVal, identityVal: identityVal})
+	}
+
+	tmpl, err := parseNamingTemplate(naming)
+	if err != nil {
+		diags = diags.Append(tfdiags.Sourceless(tfdiags.Error, "Invalid naming strategy", err.Error()))
+		return &ListResourceContents{}, diags
+	}
+
+	if collapse && canCollapseListResourceInstances(schema, instances) {
+		result, err := renderCollapsedListResourceContents(addr, schema, idSchema, pc, instances, naming, tmpl, policy)
+		if err != nil {
+			diags = diags.Append(tfdiags.Sourceless(tfdiags.Error, "Invalid naming strategy", err.Error()))
+		} else {
+			return result, diags
+		}
+	}
+
+	results := make([]GeneratedResource, 0, len(instances))
+	usedNames := make(map[string]bool)
+	for i, inst := range instances {
+		name, err := instanceName(addr.Resource.Resource.Name, i, inst.stateVal, inst.identityVal, naming, tmpl, usedNames)
+		if err != nil {
+			diags = diags.Append(tfdiags.Sourceless(tfdiags.Error, "Invalid naming strategy", err.Error()))
+			continue
+		}
+		instAddr := instanceWithName(addr, name)
+
+		content := renderResourceBlock(instAddr, schema, pc, inst.stateVal, false, true, policy)
+		imp, impDiags := writeImportBlock(instAddr, pc, idSchema, "", inst.identityVal)
+		diags = diags.Append(impDiags)
+		if impDiags.HasErrors() {
+			continue
+		}
+
+		results = append(results, GeneratedResource{
+			Addr:    instAddr,
+			content: content,
+			imp:     imp,
+		})
+	}
+
+	return &ListResourceContents{Results: results}, diags
+}
+
+// canCollapseListResourceInstances reports whether instances are
+// eligible for for_each collapsing: there must be at least one, none
+// may be missing an identity, every state must share the same cty
+// type, every identity must share the same cty type, and schema must
+// have no nested blocks (which for_each collapsing can't rewrite into
+// each.value references).
+func canCollapseListResourceInstances(schema *configschema.Block, instances []listResourceInstance) bool {
+	if len(schema.BlockTypes) > 0 || len(instances) == 0 {
+		return false
+	}
+	for _, inst := range instances {
+		if inst.identityVal == cty.NilVal || inst.identityVal.IsNull() {
+			return false
+		}
+	}
+	firstStateType := instances[0].stateVal.Type()
+	firstIdentityType := instances[0].identityVal.Type()
+	for _, inst := range instances {
+		if !inst.stateVal.Type().Equals(firstStateType) {
+			return false
+		}
+		if !inst.identityVal.Type().Equals(firstIdentityType) {
+			return false
+		}
+	}
+	return true
+}
+
+// renderCollapsedListResourceContents renders instances as a single
+// `resource "type" "name" { for_each = {...}; attr = each.value.attr
+// ... }` block, keyed the same way naming would otherwise name each
+// unrolled instance, plus a matching `import { for_each = {...} ... }`
+// block that adopts every key into that resource.
+func renderCollapsedListResourceContents(addr addrs.AbsResourceInstance, schema *configschema.Block, idSchema *configschema.Object, pc addrs.LocalProviderConfig, instances []listResourceInstance, naming NamingStrategy, tmpl *template.Template, policy FormatPolicy) (*ListResourceContents, error) {
+	resource := addr.Resource.Resource
+
+	used := make(map[string]bool)
+	keys := make([]string, len(instances))
+	for i, inst := range instances {
+		key, err := instanceName(resource.Name, i, inst.stateVal, inst.identityVal, naming, tmpl, used)
+		if err != nil {
+			return nil, err
+		}
+		keys[i] = key
+	}
+
+	var content bytes.Buffer
+	content.WriteString(fmt.Sprintf("resource %q %q {\n", resource.Type, resource.Name))
+	writeResourceProvider(&content, resource, pc, true)
+	content.WriteString("  for_each = {\n")
+	attrNames := configurableAttributeNames(schema.Attributes)
+	for i, inst := range instances {
+		content.WriteString(fmt.Sprintf("    %s = {\n", hclAttributeName(keys[i])))
+		for _, name := range attrNames {
+			attrVal, present := getAttr(inst.stateVal, name)
+			writeAttribute(&content, "      ", name, schema.Attributes[name], attrVal, present, false, policy)
+		}
+		content.WriteString("    }\n")
+	}
+	content.WriteString("  }\n")
+	for _, name := range attrNames {
+		content.WriteString(fmt.Sprintf("  %s = %s\n", hclAttributeName(name), eachValueRef(name)))
+	}
+	content.WriteString("}")
+	formattedContent := hclwrite.Format(content.Bytes())
+	content.Reset()
+	content.Write(formattedContent)
+
+	var imp bytes.Buffer
+	imp.WriteString("import {\n")
+	imp.WriteString("  for_each = {\n")
+	var idAttrs map[string]*configschema.Attribute
+	if idSchema != nil {
+		idAttrs = idSchema.Attributes
+	}
+	for i, inst := range instances {
+		imp.WriteString(fmt.Sprintf("    %s = {\n", hclAttributeName(keys[i])))
+		writeObjectAttributes(&imp, "      ", idAttrs, inst.identityVal, FormatPolicy{})
+		imp.WriteString("    }\n")
+	}
+	imp.WriteString("  }\n")
+	imp.WriteString(fmt.Sprintf("  to = %s.%s[each.key]\n", resource.Type, resource.Name))
+	writeResourceProvider(&imp, resource, pc, true)
+	imp.WriteString("  identity = each.value\n")
+	imp.WriteString("}\n")
+	formattedImp := hclwrite.Format(imp.Bytes())
+	imp.Reset()
+	imp.Write(formattedImp)
+
+	return &ListResourceContents{Results: []GeneratedResource{{
+		Addr:    addr,
+		content: &content,
+		imp:     &imp,
+	}}}, nil
+}
+
+// eachValueRef renders the expression that reads attribute name off of
+// each.value, using dotted access when name is a valid HCL identifier
+// and index access otherwise.
+func eachValueRef(name string) string {
+	if hclsyntax.ValidIdentifier(name) {
+		return "each.value." + name
+	}
+	return fmt.Sprintf("each.value[%q]", name)
+}
+
+// parseNamingTemplate parses naming.Template once up front when naming
+// selects NamingByTemplate, so a malformed template produces a single
+// diagnostic instead of one per instance. It returns a nil template (and
+// no error) for every other NamingStrategyKind.
+func parseNamingTemplate(naming NamingStrategy) (*template.Template, error) {
+	if naming.Kind != NamingByTemplate {
+		return nil, nil
+	}
+	tmpl, err := template.New("name").Parse(naming.Template)
+	if err != nil {
+		return nil, fmt.Errorf("parsing naming template: %w", err)
+	}
+	return tmpl, nil
+}
+
+// instanceName derives the resource name for the i'th result of a list
+// resource query, according to naming. defaultName is the list
+// resource's own name, used both as the NamingByIndex base and as the
+// fallback when NamingByAttribute can't find a usable value. tmpl is the
+// template parsed from naming.Template by parseNamingTemplate; it is
+// only read when naming.Kind is NamingByTemplate. Whatever name is
+// derived is slugified into a valid HCL identifier and, if it collides
+// with a name already in used, disambiguated with a numeric suffix.
+func instanceName(defaultName string, i int, stateVal, identityVal cty.Value, naming NamingStrategy, tmpl *template.Template, used map[string]bool) (string, error) {
+	switch naming.Kind {
+	case NamingByTemplate:
+		var buf bytes.Buffer
+		data := map[string]interface{}{
+			"state":    ctyToGo(stateVal),
+			"identity": ctyToGo(identityVal),
+		}
+		if err := tmpl.Execute(&buf, data); err != nil {
+			return "", fmt.Errorf("executing naming template: %w", err)
+		}
+		return disambiguate(slugify(buf.String()), used), nil
+	case NamingByAttribute:
+		if attrVal, ok := getAttr(stateVal, naming.Attribute); ok && !attrVal.IsNull() && attrVal.Type() == cty.String {
+			return disambiguate(slugify(attrVal.AsString()), used), nil
+		}
+		return disambiguate(fmt.Sprintf("%s_%d", defaultName, i), used), nil
+	default:
+		return disambiguate(fmt.Sprintf("%s_%d", defaultName, i), used), nil
+	}
+}
+
+// slugify rewrites s into a valid HCL identifier: runs of characters
+// that aren't letters, digits, underscores, or hyphens become a single
+// underscore, underscores at either end are removed (an empty result
+// becomes "_"), and a leading digit or hyphen is prefixed with an
+// underscore.
+func slugify(s string) string {
+	var b strings.Builder
+	lastWasReplaced := false
+	for _, r := range s {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_' || r == '-' {
+			b.WriteRune(r)
+			lastWasReplaced = false
+			continue
+		}
+		if !lastWasReplaced {
+			b.WriteRune('_')
+			lastWasReplaced = true
+		}
+	}
+	out := strings.Trim(b.String(), "_")
+	if out == "" {
+		return "_"
+	}
+	if first := []rune(out)[0]; unicode.IsDigit(first) || first == '-' {
+		out = "_" + out
+	}
+	return out
+}
+
+// disambiguate returns name, or name with a numeric suffix appended if
+// name is already in used, and records whatever it returns in used.
+func disambiguate(name string, used map[string]bool) string {
+	candidate := name
+	for n := 2; used[candidate]; n++ {
+		candidate = fmt.Sprintf("%s_%d", name, n)
+	}
+	used[candidate] = true
+	return candidate
+}
+
+// ctyToGo converts val into plain Go values (string, bool, float64,
+// map[string]interface{}, []interface{}) suitable for use as
+// text/template data. Unknown and null values, and types with no
+// sensible Go representation, become nil.
+func ctyToGo(val cty.Value) interface{} {
+	if val == cty.NilVal || !val.IsKnown() || val.IsNull() {
+		return nil
+	}
+	switch {
+	case val.Type() == cty.String:
+		return val.AsString()
+	case val.Type() == cty.Bool:
+		return val.True()
+	case val.Type() == cty.Number:
+		f, _ := val.AsBigFloat().Float64()
+		return f
+	case val.Type().IsObjectType() || val.Type().IsMapType():
+		m := make(map[string]interface{})
+		for it := val.ElementIterator(); it.Next(); {
+			k, v := it.Element()
+			m[k.AsString()] = ctyToGo(v)
+		}
+		return m
+	case val.Type().IsListType() || val.Type().IsSetType() || val.Type().IsTupleType():
+		s := make([]interface{}, 0, val.LengthInt())
+		for it := val.ElementIterator(); it.Next(); {
+			_, v := it.Element()
+			s = append(s, ctyToGo(v))
+		}
+		return s
+	default:
+		return nil
+	}
+}
+
+// instanceWithName builds a new resource instance address whose
+// resource name is name, used to materialize the N results of a list
+// resource query as N distinct resource blocks.
+func instanceWithName(addr addrs.AbsResourceInstance, name string) addrs.AbsResourceInstance {
+	resource := addr.Resource.Resource
+	resource.Name = name
+	return addrs.AbsResourceInstance{
+		Module: addr.Module,
+		Resource: addrs.ResourceInstance{
+			Resource: resource,
+			Key:      nil,
+		},
+	}
+}
+
+// writeImportBlock renders the `import` block that adopts addr into
+// state, using id if the provider returned a legacy import ID, or
+// identityVal (rendered against idSchema, or without schema guidance
+// when idSchema is nil) if it returned a resource identity instead.
+// If neither is set, it reports a diagnostic and
+// returns a nil buffer instead of emitting an invalid id = "" import
+// block; callers must not add that instance to their results.
+func writeImportBlock(addr addrs.AbsResourceInstance, pc addrs.LocalProviderConfig, idSchema *configschema.Object, id string, identityVal cty.Value) (*bytes.Buffer, tfdiags.Diagnostics) {
+	var diags tfdiags.Diagnostics
+
+	if (identityVal == cty.NilVal || identityVal.IsNull()) && id == "" {
+		diags = diags.Append(tfdiags.Sourceless(tfdiags.Error,
+			"Cannot generate import block",
+			fmt.Sprintf("%s has neither an import ID nor a resource identity, so Terraform cannot generate an import block to adopt it.", addr.String()),
+		))
+		return nil, diags
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString("import {\n")
+	buf.WriteString(fmt.Sprintf("  to = %s\n", addr.Resource.String()))
+	writeResourceProvider(&buf, addr.Resource.Resource, pc, true)
+	if identityVal != cty.NilVal && !identityVal.IsNull() {
+		var idAttrs map[string]*configschema.Attribute
+		if idSchema != nil {
+			idAttrs = idSchema.Attributes
+		}
+		buf.WriteString("  identity = {\n")
+		writeObjectAttributes(&buf, "    ", idAttrs, identityVal, FormatPolicy{})
+		buf.WriteString("  }\n")
+	} else {
+		buf.WriteString(fmt.Sprintf("  id = %q\n", id))
+	}
+	buf.WriteString("}\n")
+
+	formatted := hclwrite.Format(buf.Bytes())
+	buf.Reset()
+	buf.Write(formatted)
+	return &buf, diags
+}
+
+// writeResourceProvider writes the `provider = ...` meta-argument line,
+// unless pc is unset, or (when alwaysShow is false) pc is unaliased and
+// matches the provider implied by the resource's type prefix.
+func writeResourceProvider(buf *bytes.Buffer, resource addrs.Resource, pc addrs.LocalProviderConfig, alwaysShow bool) {
+	if pc.LocalName == "" {
+		return
+	}
+	if !alwaysShow && pc.Alias == "" && pc.LocalName == resource.ImpliedProvider() {
+		return
+	}
+	if pc.Alias != "" {
+		buf.WriteString(fmt.Sprintf("  provider = %s.%s\n", pc.LocalName, pc.Alias))
+	} else {
+		buf.WriteString(fmt.Sprintf("  provider = %s\n", pc.LocalName))
+	}
+}
+
+// writeBlockContents writes every attribute and nested block described
+// by schema, reading values out of val. showHints is true only when the
+// entire resource is being generated from scratch with no state at all;
+// it drives whether missing/null values get an explanatory comment.
+func writeBlockContents(buf *bytes.Buffer, indent string, schema *configschema.Block, val cty.Value, showHints bool, policy FormatPolicy) {
+	names := configurableAttributeNames(schema.Attributes)
+	for _, name := range names {
+		attrVal, present := getAttr(val, name)
+		writeAttribute(buf, indent, name, schema.Attributes[name], attrVal, present, showHints, policy)
+	}
+
+	blockNames := make([]string, 0, len(schema.BlockTypes))
+	for name := range schema.BlockTypes {
+		blockNames = append(blockNames, name)
+	}
+	sort.Strings(blockNames)
+	for _, name := range blockNames {
+		blockVal, present := getAttr(val, name)
+		writeNestedBlock(buf, indent, name, schema.BlockTypes[name], blockVal, present, showHints, policy)
+	}
+}
+
+func writeAttribute(buf *bytes.Buffer, indent, name string, attrS *configschema.Attribute, val cty.Value, present bool, showHints bool, policy FormatPolicy) {
+	if attrS.Sensitive || (present && val.HasMark(marks.Sensitive)) {
+		if policy.SensitiveValues == SensitiveValuesPlaceholder {
+			writeLine(buf, indent, name, `sensitive("...")`, "")
+		} else {
+			writeLine(buf, indent, name, "null", "sensitive")
+		}
+		return
+	}
+
+	if attrS.NestedType != nil {
+		writeNestedTypeAttribute(buf, indent, name, attrS, val, present, showHints, policy)
+		return
+	}
+
+	if !present || val.IsNull() {
+		writeLine(buf, indent, name, "null", hint(showHints, requiredOrOptional(attrS.Required)+" "+attrS.Type.FriendlyName()))
+		return
+	}
+
+	writeLine(buf, indent, name, renderValue(val, indent, policy), "")
+}
+
+func writeNestedTypeAttribute(buf *bytes.Buffer, indent, name string, attrS *configschema.Attribute, val cty.Value, present bool, showHints bool, policy FormatPolicy) {
+	obj := attrS.NestedType
+
+	if !present || val.IsNull() {
+		writeLine(buf, indent, name, "null", hint(showHints && !present, requiredOrOptional(attrS.Required)+" object"))
+		return
+	}
+
+	switch obj.Nesting {
+	case configschema.NestingSingle:
+		buf.WriteString(fmt.Sprintf("%s%s = {\n", indent, name))
+		writeNestedTypeObject(buf, indent+"  ", obj, val, showHints, policy)
+		buf.WriteString(fmt.Sprintf("%s}\n", indent))
+	case configschema.NestingMap:
+		buf.WriteString(fmt.Sprintf("%s%s = {\n", indent, name))
+		for _, key := range sortedObjectKeys(val) {
+			buf.WriteString(fmt.Sprintf("%s  %s = {\n", indent, hclAttributeName(key)))
+			writeNestedTypeObject(buf, indent+"    ", obj, val.GetAttr(key), showHints, policy)
+			buf.WriteString(fmt.Sprintf("%s  }\n", indent))
+		}
+		buf.WriteString(fmt.Sprintf("%s}\n", indent))
+	default: // NestingList, NestingSet
+		buf.WriteString(fmt.Sprintf("%s%s = [\n", indent, name))
+		for it := val.ElementIterator(); it.Next(); {
+			_, elem := it.Element()
+			buf.WriteString(fmt.Sprintf("%s  {\n", indent))
+			writeNestedTypeObject(buf, indent+"    ", obj, elem, showHints, policy)
+			buf.WriteString(fmt.Sprintf("%s  },\n", indent))
+		}
+		buf.WriteString(fmt.Sprintf("%s]\n", indent))
+	}
+}
+
+func writeNestedTypeObject(buf *bytes.Buffer, indent string, obj *configschema.Object, val cty.Value, showHints bool, policy FormatPolicy) {
+	names := make([]string, 0, len(obj.Attributes))
+	for name := range obj.Attributes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		attrVal, present := getAttr(val, name)
+		writeAttribute(buf, indent, name, obj.Attributes[name], attrVal, present, showHints, policy)
+	}
+}
+
+func writeNestedBlock(buf *bytes.Buffer, indent, name string, blockS *configschema.NestedBlock, val cty.Value, present bool, showHints bool, policy FormatPolicy) {
+	switch blockS.Nesting {
+	case configschema.NestingSingle, configschema.NestingGroup:
+		if !present {
+			required := blockS.MinItems > 0
+			buf.WriteString(fmt.Sprintf("%s%s {", indent, name))
+			if showHints {
+				buf.WriteString(fmt.Sprintf(" # %s block", requiredOrOptional(required)))
+			}
+			buf.WriteString("\n")
+			writeBlockContents(buf, indent+"  ", &blockS.Block, cty.NilVal, showHints, policy)
+			buf.WriteString(fmt.Sprintf("%s}\n", indent))
+			return
+		}
+		if val.IsNull() {
+			return
+		}
+		buf.WriteString(fmt.Sprintf("%s%s {\n", indent, name))
+		writeBlockContents(buf, indent+"  ", &blockS.Block, val, showHints, policy)
+		buf.WriteString(fmt.Sprintf("%s}\n", indent))
+	default: // NestingList, NestingSet, NestingMap
+		if !present || val.IsNull() || !val.IsKnown() || val.LengthInt() == 0 {
+			return
+		}
+		for it := val.ElementIterator(); it.Next(); {
+			_, elem := it.Element()
+			buf.WriteString(fmt.Sprintf("%s%s {\n", indent, name))
+			writeBlockContents(buf, indent+"  ", &blockS.Block, elem, showHints, policy)
+			buf.WriteString(fmt.Sprintf("%s}\n", indent))
+		}
+	}
+}
+
+func writeLine(buf *bytes.Buffer, indent, name, value, comment string) {
+	buf.WriteString(fmt.Sprintf("%s%s = %s", indent, name, value))
+	if comment != "" {
+		buf.WriteString(" # " + comment)
+	}
+	buf.WriteString("\n")
+}
+
+func hint(showHints bool, text string) string {
+	if !showHints {
+		return ""
+	}
+	return text
+}
+
+func requiredOrOptional(required bool) string {
+	if required {
+		return "REQUIRED"
+	}
+	return "OPTIONAL"
+}
+
+// configurableAttributeNames returns the names of attrs that a user
+// could plausibly configure, sorted: purely computed attributes are
+// never user-configurable, so there is nothing useful to generate for
+// them.
+func configurableAttributeNames(attrs map[string]*configschema.Attribute) []string {
+	names := make([]string, 0, len(attrs))
+	for name, attrS := range attrs {
+		if attrS.Computed && !attrS.Optional {
+			continue
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// renderValue renders a known, non-null, non-sensitive cty.Value as an
+// HCL expression.
+func renderValue(val cty.Value, indent string, policy FormatPolicy) string {
+	switch {
+	case val.Type() == cty.String:
+		s := val.AsString()
+		if expr, ok := renderJSONString(s, indent, policy); ok {
+			return expr
+		}
+		if expr, ok := renderHeredoc(s, indent, policy); ok {
+			return expr
+		}
+		return strconv.Quote(s)
+	case val.Type() == cty.Bool:
+		if val.True() {
+			return "true"
+		}
+		return "false"
+	case val.Type() == cty.Number:
+		return val.AsBigFloat().Text('f', -1)
+	case val.Type().IsObjectType() || val.Type().IsMapType():
+		var buf bytes.Buffer
+		buf.WriteString("{\n")
+		writeObjectAttributes(&buf, indent+"  ", nil, val, policy)
+		buf.WriteString(indent + "}")
+		return buf.String()
+	case val.Type().IsListType() || val.Type().IsSetType() || val.Type().IsTupleType():
+		elems := make([]string, 0)
+		for it := val.ElementIterator(); it.Next(); {
+			_, elem := it.Element()
+			elems = append(elems, renderValue(elem, indent, policy))
+		}
+		return "[" + joinComma(elems) + "]"
+	default:
+		return strconv.Quote(val.AsString())
+	}
+}
+
+// renderHeredoc checks whether s is eligible, under policy, to be
+// rendered as a `<<-EOT` heredoc rather than an escaped quoted string:
+// policy.HeredocMinLines must be positive, and s must contain at least
+// that many lines.
+func renderHeredoc(s string, indent string, policy FormatPolicy) (string, bool) {
+	if policy.HeredocMinLines <= 0 {
+		return "", false
+	}
+	lines := strings.Split(s, "\n")
+	if len(lines) < policy.HeredocMinLines {
+		return "", false
+	}
+
+	marker := heredocMarker(lines)
+
+	var buf bytes.Buffer
+	buf.WriteString("<<-" + marker + "\n")
+	for _, line := range lines {
+		buf.WriteString(indent + line + "\n")
+	}
+	buf.WriteString(indent + marker)
+	return buf.String(), true
+}
+
+// heredocMarker returns a heredoc terminator that's guaranteed not to
+// collide with any line of content: "EOT", falling back to "EOT1",
+// "EOT2", and so on until it finds one that doesn't appear verbatim as
+// one of lines.
+func heredocMarker(lines []string) string {
+	marker := "EOT"
+	for n := 1; ; n++ {
+		collides := false
+		for _, line := range lines {
+			if line == marker {
+				collides = true
+				break
+			}
+		}
+		if !collides {
+			return marker
+		}
+		marker = fmt.Sprintf("EOT%d", n)
+	}
+}
+
+func joinComma(elems []string) string {
+	out := ""
+	for i, e := range elems {
+		if i > 0 {
+			out += ", "
+		}
+		out += e
+	}
+	return out
+}
+
+// writeObjectAttributes writes the attributes of a cty object or map
+// value, one per line, sorted by key. attrs may be nil, in which case
+// every key present in val is written with no schema guidance.
+func writeObjectAttributes(buf *bytes.Buffer, indent string, attrs map[string]*configschema.Attribute, val cty.Value, policy FormatPolicy) {
+	for _, key := range sortedObjectKeys(val) {
+		elemVal := val.GetAttr(key)
+		if attrs != nil {
+			if attrS, ok := attrs[key]; ok {
+				writeAttribute(buf, indent, key, attrS, elemVal, true, false, policy)
+				continue
+			}
+		}
+		writeLine(buf, indent, hclAttributeName(key), renderValue(elemVal, indent, policy), "")
+	}
+}
+
+func sortedObjectKeys(val cty.Value) []string {
+	keys := make([]string, 0, val.LengthInt())
+	for it := val.ElementIterator(); it.Next(); {
+		k, _ := it.Element()
+		keys = append(keys, k.AsString())
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// renderJSONString checks whether s is a compound (object or array)
+// JSON document at least policy.JSONEncodeMinChars long, and if so
+// renders it as a jsonencode(...) expression instead of an escaped
+// string literal. Scalars, malformed JSON, and payloads shorter than
+// the policy's threshold fall through, so callers can render them as
+// plain strings.
+func renderJSONString(s string, indent string, policy FormatPolicy) (string, bool) {
+	if len(s) < policy.JSONEncodeMinChars {
+		return "", false
+	}
+	var raw interface{}
+	if err := json.Unmarshal([]byte(s), &raw); err != nil {
+		return "", false
+	}
+	switch raw.(type) {
+	case map[string]interface{}, []interface{}:
+	default:
+		return "", false
+	}
+	return fmt.Sprintf("jsonencode(%s)", renderJSONValue(raw, indent)), true
+}
+
+func renderJSONValue(raw interface{}, indent string) string {
+	switch v := raw.(type) {
+	case nil:
+		return "null"
+	case bool:
+		if v {
+			return "true"
+		}
+		return "false"
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case string:
+		return strconv.Quote(v)
+	case []interface{}:
+		elems := make([]string, len(v))
+		for i, e := range v {
+			elems[i] = renderJSONValue(e, indent)
+		}
+		return "[" + joinComma(elems) + "]"
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		var buf bytes.Buffer
+		buf.WriteString("{\n")
+		for _, k := range keys {
+			buf.WriteString(fmt.Sprintf("%s  %s = %s\n", indent, hclAttributeName(k), renderJSONValue(v[k], indent+"  ")))
+		}
+		buf.WriteString(indent + "}")
+		return buf.String()
+	default:
+		return "null"
+	}
+}
+
+// hclAttributeName quotes key if it is not a valid bare HCL identifier.
+func hclAttributeName(key string) string {
+	if hclsyntax.ValidIdentifier(key) {
+		return key
+	}
+	return strconv.Quote(key)
+}
+
+// getAttr reads name out of val, reporting false if val itself is
+// cty.NilVal or null (there is no state to read from at all), or val's
+// type has no such attribute (the state predates this attribute, or
+// this call is building a from-scratch skeleton).
+func getAttr(val cty.Value, name string) (cty.Value, bool) {
+	if val == cty.NilVal || !val.IsKnown() || val.IsNull() {
+		return cty.NilVal, false
+	}
+	if !val.Type().HasAttribute(name) {
+		return cty.NilVal, false
+	}
+	return val.GetAttr(name), true
+}
@@ -4,6 +4,7 @@
 package genconfig
 
 import (
+	"fmt"
 	"strings"
 	"testing"
 
@@ -21,6 +22,7 @@ func TestConfigGeneration(t *testing.T) {
 		addr     addrs.AbsResourceInstance
 		provider addrs.LocalProviderConfig
 		value    cty.Value
+		policy   FormatPolicy
 		expected string
 	}{
 		"simple_resource": {
@@ -816,6 +818,146 @@ resource "tfcoremock_sensitive_values" "values" {
       value = "underscores"
     }
   }
+}`,
+		},
+		"stringified_json_object_below_min_chars_policy": {
+			// A JSONEncodeMinChars policy leaves short JSON payloads as
+			// quoted strings instead of rewriting them as jsonencode(...).
+			schema: &configschema.Block{
+				Attributes: map[string]*configschema.Attribute{
+					"value": {
+						Type:     cty.String,
+						Optional: true,
+					},
+				},
+			},
+			addr: addrs.AbsResourceInstance{
+				Module: addrs.RootModuleInstance,
+				Resource: addrs.ResourceInstance{
+					Resource: addrs.Resource{
+						Mode: addrs.ManagedResourceMode,
+						Type: "tfcoremock_simple_resource",
+						Name: "empty",
+					},
+					Key: addrs.NoKey,
+				},
+			},
+			provider: addrs.LocalProviderConfig{
+				LocalName: "tfcoremock",
+			},
+			value: cty.ObjectVal(map[string]cty.Value{
+				"value": cty.StringVal(`{"a":1}`),
+			}),
+			policy: FormatPolicy{
+				JSONEncodeMinChars: 100,
+			},
+			expected: `resource "tfcoremock_simple_resource" "empty" {
+  value = "{\"a\":1}"
+}`,
+		},
+		"sensitive_values_with_placeholder_policy": {
+			schema: &configschema.Block{
+				Attributes: map[string]*configschema.Attribute{
+					"string": sensitiveAttribute(cty.String),
+				},
+			},
+			addr: addrs.AbsResourceInstance{
+				Module: addrs.RootModuleInstance,
+				Resource: addrs.ResourceInstance{
+					Resource: addrs.Resource{
+						Mode: addrs.ManagedResourceMode,
+						Type: "tfcoremock_sensitive_values",
+						Name: "values",
+					},
+					Key: addrs.NoKey,
+				},
+			},
+			provider: addrs.LocalProviderConfig{
+				LocalName: "tfcoremock",
+			},
+			value: cty.ObjectVal(map[string]cty.Value{
+				"string": cty.StringVal("Hello, world!").Mark(marks.Sensitive),
+			}),
+			policy: FormatPolicy{
+				SensitiveValues: SensitiveValuesPlaceholder,
+			},
+			expected: `resource "tfcoremock_sensitive_values" "values" {
+  string = sensitive("...")
+}`,
+		},
+		"multiline_string_as_heredoc_policy": {
+			schema: &configschema.Block{
+				Attributes: map[string]*configschema.Attribute{
+					"value": {
+						Type:     cty.String,
+						Optional: true,
+					},
+				},
+			},
+			addr: addrs.AbsResourceInstance{
+				Module: addrs.RootModuleInstance,
+				Resource: addrs.ResourceInstance{
+					Resource: addrs.Resource{
+						Mode: addrs.ManagedResourceMode,
+						Type: "tfcoremock_simple_resource",
+						Name: "empty",
+					},
+					Key: addrs.NoKey,
+				},
+			},
+			provider: addrs.LocalProviderConfig{
+				LocalName: "tfcoremock",
+			},
+			value: cty.ObjectVal(map[string]cty.Value{
+				"value": cty.StringVal("line one\nline two\nline three"),
+			}),
+			policy: FormatPolicy{
+				HeredocMinLines: 3,
+			},
+			expected: `resource "tfcoremock_simple_resource" "empty" {
+  value = <<-EOT
+  line one
+  line two
+  line three
+  EOT
+}`,
+		},
+		"multiline_string_containing_heredoc_marker_as_heredoc_policy": {
+			schema: &configschema.Block{
+				Attributes: map[string]*configschema.Attribute{
+					"value": {
+						Type:     cty.String,
+						Optional: true,
+					},
+				},
+			},
+			addr: addrs.AbsResourceInstance{
+				Module: addrs.RootModuleInstance,
+				Resource: addrs.ResourceInstance{
+					Resource: addrs.Resource{
+						Mode: addrs.ManagedResourceMode,
+						Type: "tfcoremock_simple_resource",
+						Name: "empty",
+					},
+					Key: addrs.NoKey,
+				},
+			},
+			provider: addrs.LocalProviderConfig{
+				LocalName: "tfcoremock",
+			},
+			value: cty.ObjectVal(map[string]cty.Value{
+				"value": cty.StringVal("line one\nEOT\nEOT1\nline four"),
+			}),
+			policy: FormatPolicy{
+				HeredocMinLines: 3,
+			},
+			expected: `resource "tfcoremock_simple_resource" "empty" {
+  value = <<-EOT2
+  line one
+  EOT
+  EOT1
+  line four
+  EOT2
 }`,
 		},
 	}
@@ -825,7 +967,7 @@ resource "tfcoremock_sensitive_values" "values" {
 			if err != nil {
 				t.Fatalf("schema failed InternalValidate: %s", err)
 			}
-			contents, diags := GenerateResourceContents(tc.addr, tc.schema, tc.provider, tc.value, false)
+			contents, diags := GenerateResourceContents(tc.addr, tc.schema, tc.provider, tc.value, tc.policy)
 			if len(diags) > 0 {
 				t.Errorf("expected no diagnostics but found %s", diags)
 			}
@@ -954,7 +1096,7 @@ func TestGenerateResourceAndIDContents(t *testing.T) {
 	}
 
 	// Generate content
-	content, diags := GenerateListResourceContents(instAddr1, schema, idSchema, pc, value)
+	content, diags := GenerateListResourceContents(instAddr1, schema, idSchema, pc, value, FormatPolicy{}, NamingStrategy{}, false)
 	// Check for diagnostics
 	if diags.HasErrors() {
 		t.Fatalf("unexpected diagnostics: %s", diags.Err())
@@ -1024,3 +1166,551 @@ import {
 		t.Errorf("Generated content doesn't match expected. want:\n%s\ngot:\n%s\ndiff:\n%s", normalizedExpected, normalizedActual, diff)
 	}
 }
+
+func TestGenerateResourceAndImportContents(t *testing.T) {
+	schema := &configschema.Block{
+		Attributes: map[string]*configschema.Attribute{
+			"name": {
+				Type:     cty.String,
+				Optional: true,
+			},
+			"id": {
+				Type:     cty.String,
+				Computed: true,
+			},
+		},
+	}
+
+	idSchema := &configschema.Object{
+		Nesting: configschema.NestingSingle,
+		Attributes: map[string]*configschema.Attribute{
+			"id": {
+				Type:     cty.String,
+				Optional: true,
+			},
+		},
+	}
+
+	addr := addrs.AbsResource{
+		Module: addrs.RootModuleInstance,
+		Resource: addrs.Resource{
+			Mode: addrs.ManagedResourceMode,
+			Type: "aws_instance",
+			Name: "example",
+		},
+	}
+
+	pc := addrs.LocalProviderConfig{
+		LocalName: "aws",
+	}
+
+	// Instances are passed out of key order, and mix a legacy import ID
+	// with a resource identity, to confirm both are rendered correctly
+	// and that the output is sorted by key regardless of input order.
+	instances := []ResourceImportInstance{
+		{
+			Key: addrs.StringKey("b"),
+			StateVal: cty.ObjectVal(map[string]cty.Value{
+				"name": cty.StringVal("instance-b"),
+				"id":   cty.StringVal("i-bbb"),
+			}),
+			Identity: cty.ObjectVal(map[string]cty.Value{
+				"id": cty.StringVal("i-bbb"),
+			}),
+		},
+		{
+			Key: addrs.StringKey("a"),
+			StateVal: cty.ObjectVal(map[string]cty.Value{
+				"name": cty.StringVal("instance-a"),
+				"id":   cty.StringVal("i-aaa"),
+			}),
+			ImportID: "i-aaa",
+		},
+	}
+
+	content, diags := GenerateResourceAndImportContents(addr, schema, idSchema, pc, instances, FormatPolicy{})
+	if diags.HasErrors() {
+		t.Fatalf("unexpected diagnostics: %s", diags.Err())
+	}
+
+	expected := `resource "aws_instance" "example" {
+  provider = aws
+  name     = "instance-a"
+}
+import {
+  to       = aws_instance.example["a"]
+  provider = aws
+  id       = "i-aaa"
+}
+
+resource "aws_instance" "example" {
+  provider = aws
+  name     = "instance-b"
+}
+import {
+  to       = aws_instance.example["b"]
+  provider = aws
+  identity = {
+    id = "i-bbb"
+  }
+}
+`
+
+	if diff := cmp.Diff(expected, content.String()); diff != "" {
+		t.Errorf("Generated content doesn't match expected. diff:\n%s", diff)
+	}
+}
+
+func TestGenerateResourceAndImportContentsIntKeyOrder(t *testing.T) {
+	schema := &configschema.Block{
+		Attributes: map[string]*configschema.Attribute{
+			"name": {
+				Type:     cty.String,
+				Optional: true,
+			},
+		},
+	}
+
+	addr := addrs.AbsResource{
+		Module: addrs.RootModuleInstance,
+		Resource: addrs.Resource{
+			Mode: addrs.ManagedResourceMode,
+			Type: "aws_instance",
+			Name: "example",
+		},
+	}
+
+	pc := addrs.LocalProviderConfig{
+		LocalName: "aws",
+	}
+
+	// Keys are passed out of order and include keys above nine, so a
+	// naive lexicographic sort by Key.String() would interleave
+	// "[10]" and "[11]" between "[1]" and "[2]".
+	instances := make([]ResourceImportInstance, 0, 12)
+	for _, i := range []int{11, 2, 0, 10, 1} {
+		instances = append(instances, ResourceImportInstance{
+			Key: addrs.IntKey(i),
+			StateVal: cty.ObjectVal(map[string]cty.Value{
+				"name": cty.StringVal(fmt.Sprintf("instance-%d", i)),
+			}),
+			ImportID: fmt.Sprintf("i-%d", i),
+		})
+	}
+
+	content, diags := GenerateResourceAndImportContents(addr, schema, nil, pc, instances, FormatPolicy{})
+	if diags.HasErrors() {
+		t.Fatalf("unexpected diagnostics: %s", diags.Err())
+	}
+
+	got := make([]string, len(content.Results))
+	for i, r := range content.Results {
+		got[i] = r.Addr.String()
+	}
+	want := []string{
+		"aws_instance.example[0]",
+		"aws_instance.example[1]",
+		"aws_instance.example[2]",
+		"aws_instance.example[10]",
+		"aws_instance.example[11]",
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("instances sorted in wrong order. diff:\n%s", diff)
+	}
+}
+
+func TestGenerateResourceAndImportContentsMissingIdentity(t *testing.T) {
+	schema := &configschema.Block{
+		Attributes: map[string]*configschema.Attribute{
+			"name": {
+				Type:     cty.String,
+				Optional: true,
+			},
+		},
+	}
+
+	addr := addrs.AbsResource{
+		Module: addrs.RootModuleInstance,
+		Resource: addrs.Resource{
+			Mode: addrs.ManagedResourceMode,
+			Type: "aws_instance",
+			Name: "example",
+		},
+	}
+
+	pc := addrs.LocalProviderConfig{
+		LocalName: "aws",
+	}
+
+	// Neither ImportID nor Identity is populated, so genconfig should
+	// report a diagnostic rather than emit an invalid id = "" import
+	// block.
+	instances := []ResourceImportInstance{
+		{
+			Key: addrs.StringKey("a"),
+			StateVal: cty.ObjectVal(map[string]cty.Value{
+				"name": cty.StringVal("instance-a"),
+			}),
+		},
+	}
+
+	content, diags := GenerateResourceAndImportContents(addr, schema, nil, pc, instances, FormatPolicy{})
+	if !diags.HasErrors() {
+		t.Fatalf("expected a diagnostic for an instance with neither an import ID nor an identity")
+	}
+	if len(content.Results) != 0 {
+		t.Fatalf("expected no results for an instance that failed to generate an import block, got %d", len(content.Results))
+	}
+}
+
+func TestGenerateListResourceContentsNaming(t *testing.T) {
+	schema := &configschema.Block{
+		Attributes: map[string]*configschema.Attribute{
+			"name": {
+				Type:     cty.String,
+				Optional: true,
+			},
+		},
+	}
+
+	addr := addrs.AbsResourceInstance{
+		Module: addrs.RootModuleInstance,
+		Resource: addrs.ResourceInstance{
+			Resource: addrs.Resource{
+				Mode: addrs.ListResourceMode,
+				Type: "aws_instance",
+				Name: "example",
+			},
+			Key: addrs.NoKey,
+		},
+	}
+
+	pc := addrs.LocalProviderConfig{
+		LocalName: "aws",
+	}
+
+	value := cty.TupleVal([]cty.Value{
+		cty.ObjectVal(map[string]cty.Value{
+			"state": cty.ObjectVal(map[string]cty.Value{
+				"name": cty.StringVal("My Instance!"),
+			}),
+			"identity": cty.ObjectVal(map[string]cty.Value{
+				"id": cty.StringVal("i-abcdef"),
+			}),
+		}),
+		cty.ObjectVal(map[string]cty.Value{
+			"state": cty.ObjectVal(map[string]cty.Value{
+				"name": cty.StringVal("My Instance!"),
+			}),
+			"identity": cty.ObjectVal(map[string]cty.Value{
+				"id": cty.StringVal("i-123456"),
+			}),
+		}),
+	})
+
+	t.Run("by_template", func(t *testing.T) {
+		content, diags := GenerateListResourceContents(addr, schema, nil, pc, value, FormatPolicy{}, NamingStrategy{
+			Kind:     NamingByTemplate,
+			Template: "{{ .identity.id }}",
+		}, false)
+		if diags.HasErrors() {
+			t.Fatalf("unexpected diagnostics: %s", diags.Err())
+		}
+		var names []string
+		for _, r := range content.Results {
+			names = append(names, r.Addr.Resource.Resource.Name)
+		}
+		want := []string{"i-abcdef", "i-123456"}
+		if diff := cmp.Diff(want, names); diff != "" {
+			t.Errorf("unexpected names, diff:\n%s", diff)
+		}
+	})
+
+	t.Run("by_attribute_with_collision", func(t *testing.T) {
+		// Both instances share the same "name" attribute, so the
+		// second must be disambiguated.
+		content, diags := GenerateListResourceContents(addr, schema, nil, pc, value, FormatPolicy{}, NamingStrategy{
+			Kind:      NamingByAttribute,
+			Attribute: "name",
+		}, false)
+		if diags.HasErrors() {
+			t.Fatalf("unexpected diagnostics: %s", diags.Err())
+		}
+		var names []string
+		for _, r := range content.Results {
+			names = append(names, r.Addr.Resource.Resource.Name)
+		}
+		want := []string{"My_Instance", "My_Instance_2"}
+		if diff := cmp.Diff(want, names); diff != "" {
+			t.Errorf("unexpected names, diff:\n%s", diff)
+		}
+	})
+
+	t.Run("invalid_template_reports_once", func(t *testing.T) {
+		// The template is parsed once up front, so a malformed template
+		// should report a single diagnostic regardless of how many
+		// instances there are, not one per instance.
+		content, diags := GenerateListResourceContents(addr, schema, nil, pc, value, FormatPolicy{}, NamingStrategy{
+			Kind:     NamingByTemplate,
+			Template: "{{ .identity.id",
+		}, false)
+		if len(content.Results) != 0 {
+			t.Errorf("expected no results for an invalid template, got %d", len(content.Results))
+		}
+		if got := len(diags); got != 1 {
+			t.Fatalf("expected exactly one diagnostic, got %d: %s", got, diags.Err())
+		}
+	})
+}
+
+func TestGenerateListResourceContentsCollapsed(t *testing.T) {
+	schema := &configschema.Block{
+		Attributes: map[string]*configschema.Attribute{
+			"name": {
+				Type:     cty.String,
+				Optional: true,
+			},
+			"id": {
+				Type:     cty.String,
+				Computed: true,
+			},
+		},
+	}
+
+	idSchema := &configschema.Object{
+		Nesting: configschema.NestingSingle,
+		Attributes: map[string]*configschema.Attribute{
+			"id": {
+				Type:     cty.String,
+				Optional: true,
+			},
+		},
+	}
+
+	addr := addrs.AbsResourceInstance{
+		Module: addrs.RootModuleInstance,
+		Resource: addrs.ResourceInstance{
+			Resource: addrs.Resource{
+				Mode: addrs.ListResourceMode,
+				Type: "aws_instance",
+				Name: "example",
+			},
+			Key: addrs.NoKey,
+		},
+	}
+
+	pc := addrs.LocalProviderConfig{
+		LocalName: "aws",
+	}
+
+	value := cty.TupleVal([]cty.Value{
+		cty.ObjectVal(map[string]cty.Value{
+			"state": cty.ObjectVal(map[string]cty.Value{
+				"name": cty.StringVal("instance-1"),
+				"id":   cty.StringVal("i-abcdef"),
+			}),
+			"identity": cty.ObjectVal(map[string]cty.Value{
+				"id": cty.StringVal("i-abcdef"),
+			}),
+		}),
+		cty.ObjectVal(map[string]cty.Value{
+			"state": cty.ObjectVal(map[string]cty.Value{
+				"name": cty.StringVal("instance-2"),
+				"id":   cty.StringVal("i-123456"),
+			}),
+			"identity": cty.ObjectVal(map[string]cty.Value{
+				"id": cty.StringVal("i-123456"),
+			}),
+		}),
+	})
+
+	content, diags := GenerateListResourceContents(addr, schema, idSchema, pc, value, FormatPolicy{}, NamingStrategy{
+		Kind:     NamingByTemplate,
+		Template: "{{ .identity.id }}",
+	}, true)
+	if diags.HasErrors() {
+		t.Fatalf("unexpected diagnostics: %s", diags.Err())
+	}
+
+	if len(content.Results) != 1 {
+		t.Fatalf("expected a single collapsed resource/import pair, got %d", len(content.Results))
+	}
+
+	expected := `resource "aws_instance" "example" {
+  provider = aws
+  for_each = {
+    i-abcdef = {
+      name = "instance-1"
+    }
+    i-123456 = {
+      name = "instance-2"
+    }
+  }
+  name = each.value.name
+}
+import {
+  for_each = {
+    i-abcdef = {
+      id = "i-abcdef"
+    }
+    i-123456 = {
+      id = "i-123456"
+    }
+  }
+  to       = aws_instance.example[each.key]
+  provider = aws
+  identity = each.value
+}
+`
+
+	if diff := cmp.Diff(expected, content.String()); diff != "" {
+		t.Errorf("Generated content doesn't match expected. diff:\n%s", diff)
+	}
+}
+
+func TestGenerateListResourceContentsCollapsedFallback(t *testing.T) {
+	// A nested block makes the instances ineligible for collapsing, so
+	// the output should fall back to the normal unrolled form.
+	schema := &configschema.Block{
+		Attributes: map[string]*configschema.Attribute{
+			"name": {
+				Type:     cty.String,
+				Optional: true,
+			},
+		},
+		BlockTypes: map[string]*configschema.NestedBlock{
+			"network_interface": {
+				Nesting: configschema.NestingList,
+				Block: configschema.Block{
+					Attributes: map[string]*configschema.Attribute{
+						"subnet_id": {
+							Type:     cty.String,
+							Required: true,
+						},
+					},
+				},
+			},
+		},
+	}
+
+	addr := addrs.AbsResourceInstance{
+		Module: addrs.RootModuleInstance,
+		Resource: addrs.ResourceInstance{
+			Resource: addrs.Resource{
+				Mode: addrs.ListResourceMode,
+				Type: "aws_instance",
+				Name: "example",
+			},
+			Key: addrs.NoKey,
+		},
+	}
+
+	pc := addrs.LocalProviderConfig{
+		LocalName: "aws",
+	}
+
+	value := cty.TupleVal([]cty.Value{
+		cty.ObjectVal(map[string]cty.Value{
+			"state": cty.ObjectVal(map[string]cty.Value{
+				"name": cty.StringVal("instance-1"),
+				"network_interface": cty.ListVal([]cty.Value{
+					cty.ObjectVal(map[string]cty.Value{
+						"subnet_id": cty.StringVal("subnet-123"),
+					}),
+				}),
+			}),
+			"identity": cty.ObjectVal(map[string]cty.Value{
+				"id": cty.StringVal("i-abcdef"),
+			}),
+		}),
+	})
+
+	content, diags := GenerateListResourceContents(addr, schema, nil, pc, value, FormatPolicy{}, NamingStrategy{}, true)
+	if diags.HasErrors() {
+		t.Fatalf("unexpected diagnostics: %s", diags.Err())
+	}
+	if len(content.Results) != 1 {
+		t.Fatalf("expected the unrolled fallback to produce one result per instance, got %d", len(content.Results))
+	}
+	if content.Results[0].Addr.Resource.Resource.Name != "example_0" {
+		t.Errorf("expected fallback naming to apply, got name %q", content.Results[0].Addr.Resource.Resource.Name)
+	}
+}
+
+func TestGenerateListResourceContentsCollapsedNilIdentitySchema(t *testing.T) {
+	// Instances are otherwise eligible for collapsing (no nested blocks,
+	// every instance carries an identity), but the provider didn't
+	// supply an identity schema. The collapsed import block must still
+	// render the real identity attributes, not a silently empty {}.
+	schema := &configschema.Block{
+		Attributes: map[string]*configschema.Attribute{
+			"name": {
+				Type:     cty.String,
+				Optional: true,
+			},
+		},
+	}
+
+	addr := addrs.AbsResourceInstance{
+		Module: addrs.RootModuleInstance,
+		Resource: addrs.ResourceInstance{
+			Resource: addrs.Resource{
+				Mode: addrs.ListResourceMode,
+				Type: "aws_instance",
+				Name: "example",
+			},
+			Key: addrs.NoKey,
+		},
+	}
+
+	pc := addrs.LocalProviderConfig{
+		LocalName: "aws",
+	}
+
+	value := cty.TupleVal([]cty.Value{
+		cty.ObjectVal(map[string]cty.Value{
+			"state": cty.ObjectVal(map[string]cty.Value{
+				"name": cty.StringVal("instance-1"),
+			}),
+			"identity": cty.ObjectVal(map[string]cty.Value{
+				"id": cty.StringVal("i-abcdef"),
+			}),
+		}),
+	})
+
+	content, diags := GenerateListResourceContents(addr, schema, nil, pc, value, FormatPolicy{}, NamingStrategy{
+		Kind:     NamingByTemplate,
+		Template: "{{ .identity.id }}",
+	}, true)
+	if diags.HasErrors() {
+		t.Fatalf("unexpected diagnostics: %s", diags.Err())
+	}
+	if len(content.Results) != 1 {
+		t.Fatalf("expected a single collapsed resource/import pair, got %d", len(content.Results))
+	}
+
+	expected := `resource "aws_instance" "example" {
+  provider = aws
+  for_each = {
+    i-abcdef = {
+      name = "instance-1"
+    }
+  }
+  name = each.value.name
+}
+import {
+  for_each = {
+    i-abcdef = {
+      id = "i-abcdef"
+    }
+  }
+  to       = aws_instance.example[each.key]
+  provider = aws
+  identity = each.value
+}
+`
+
+	if diff := cmp.Diff(expected, content.String()); diff != "" {
+		t.Errorf("Generated content doesn't match expected. diff:\n%s", diff)
+	}
+}